@@ -0,0 +1,48 @@
+/*
+attwatch is a small example that subscribes to a gpsd daemon with
+"pps":true,"json":true and prints ATT and TPV reports as they arrive,
+interleaved in arrival order, to demonstrate wiring an AHRS panel
+straight off gpsd.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gpsd"
+)
+
+func main() {
+	glog := gpsd.NewLog(os.Stderr, "attwatch: ")
+
+	src := &gpsd.Context{
+		Type: "tcp",
+		Host: "localhost",
+		Port: "2947",
+		GLog: glog,
+		Watch: gpsd.WATCH{
+			Enable: true,
+			Json:   true,
+			Pps:    true,
+		},
+	}
+
+	gpsDataChan := make(chan interface{})
+	go gpsd.ConnGPSD(src, gpsDataChan)
+
+	for msg := range gpsDataChan {
+		switch m := msg.(type) {
+		case *gpsd.ATT:
+			fmt.Printf("ATT  heading=%v pitch=%v roll=%v\n",
+				m.Heading, m.Pitch, m.Roll)
+		case *gpsd.TPV:
+			fmt.Printf("TPV  lat=%v lon=%v mode=%d\n",
+				m.Lat, m.Lon, m.Mode)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Backend openers for Context.Type values that are not plain net.Dial()
+calls: local serial GPS receivers and saved-log replay files.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial" // for serial.OpenPort()
+)
+
+// defaults for Type == "serial" when Context leaves Bps/Stopbits unset.
+const (
+	defaultBps      = 4800
+	defaultStopbits = 1
+)
+
+// openSerial() opens Context.Device (e.g. "/dev/ttyUSB0") as a local
+// serial GPS, using the Bps/Parity/Stopbits already stashed in src.
+func openSerial(src *Context) (io.ReadWriteCloser, error) {
+
+	bps := src.Bps
+	if 0 == bps {
+		bps = defaultBps
+	}
+	stopbits := src.Stopbits
+	if 0 == stopbits {
+		stopbits = defaultStopbits
+	}
+
+	var parity serial.Parity
+	switch strings.ToUpper(src.Parity) {
+	case "", "N":
+		parity = serial.ParityNone
+	case "E":
+		parity = serial.ParityEven
+	case "O":
+		parity = serial.ParityOdd
+	default:
+		return nil, errors.New(fmt.Sprintf(
+			"Unsupported serial parity '%s'", src.Parity))
+	}
+
+	conn, err := serial.OpenPort(&serial.Config{
+		Name:        src.Device,
+		Baud:        bps,
+		Parity:      parity,
+		StopBits:    serial.StopBits(stopbits),
+		ReadTimeout: time.Second,
+	})
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf(
+			"Failed to open serial device '%s': %v", src.Device, err))
+	}
+	return &serialConn{port: conn}, nil
+}
+
+// serialConn wraps a *serial.Port so that an idle port never surfaces
+// a (0, nil) read to the caller: tarm/serial returns exactly that once
+// its ReadTimeout elapses without data, and bufio.Scanner treats 100 of
+// those in a row as io.ErrNoProgress, which Reader() would otherwise
+// report as a dropped connection. Retrying internally turns "no data
+// yet" back into an ordinary blocking read.
+type serialConn struct {
+	port *serial.Port
+}
+
+func (c *serialConn) Read(p []byte) (int, error) {
+	for {
+		n, err := c.port.Read(p)
+		if 0 != n || nil != err {
+			return n, err
+		}
+	}
+}
+
+func (c *serialConn) Write(p []byte) (int, error) {
+	return c.port.Write(p)
+}
+
+func (c *serialConn) Close() error {
+	return c.port.Close()
+}
+
+// fileReplay streams a captured gpsd JSON or NMEA log line-by-line,
+// implementing io.ReadWriteCloser so it can stand in for a live
+// connection in Reader()/Writer(). Writes (e.g. a WATCH request) are
+// accepted and discarded, since a replay has no daemon to talk to.
+type fileReplay struct {
+	file     *os.File
+	scanner  *bufio.Scanner
+	pace     bool // true: sleep one second before each line
+	leftover []byte
+}
+
+// openFile() opens Context.Filename for replay.  Pace defaults to one
+// line per second; set Context.Replay to stream
+// the file as fast as the consumer can read it instead.
+func openFile(src *Context) (io.ReadWriteCloser, error) {
+	f, err := os.Open(src.Filename)
+	if nil != err {
+		return nil, errors.New(fmt.Sprintf(
+			"Failed to open replay file '%s': %v", src.Filename, err))
+	}
+	return &fileReplay{
+		file:    f,
+		scanner: bufio.NewScanner(f),
+		pace:    !src.Replay,
+	}, nil
+}
+
+// Read() feeds the next line of the replay file into p, pacing output
+// at roughly a 1Hz reporting cadence unless Replay was set.
+func (fr *fileReplay) Read(p []byte) (int, error) {
+	if 0 == len(fr.leftover) {
+		if !fr.scanner.Scan() {
+			if err := fr.scanner.Err(); nil != err {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		if fr.pace {
+			time.Sleep(time.Second)
+		}
+		fr.leftover = append(fr.scanner.Bytes(), '\n')
+	}
+	n := copy(p, fr.leftover)
+	fr.leftover = fr.leftover[n:]
+	return n, nil
+}
+
+// Write() discards everything; a replay file has no daemon listening.
+func (fr *fileReplay) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Close() closes the underlying replay file.
+func (fr *fileReplay) Close() error {
+	return fr.file.Close()
+}
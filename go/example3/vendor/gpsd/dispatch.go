@@ -0,0 +1,106 @@
+/*
+messageHandlers is the Class -> decoder registry Reader() dispatches
+through, so new gpsd JSON message classes can be added by registering
+a decoder here instead of growing a hard-coded switch.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import "encoding/json" // for json.Unmarshal()
+
+// messageHandlers maps a GPSD JSON message Class to a decoder that
+// unmarshals a raw message line into the matching struct.
+var messageHandlers = map[string]func([]byte) (interface{}, error){}
+
+// registerClass() adds (or replaces) the decoder for a GPSD message
+// Class. Called from this package's init() for every class gpsd knows
+// about.
+func registerClass(class string, decode func([]byte) (interface{}, error)) {
+	messageHandlers[class] = decode
+}
+
+func init() {
+	registerClass("ATT", func(line []byte) (interface{}, error) {
+		att := NewATT()
+		err := json.Unmarshal(line, att)
+		return att, err
+	})
+	registerClass("IMU", func(line []byte) (interface{}, error) {
+		imu := NewIMU()
+		err := json.Unmarshal(line, imu)
+		return imu, err
+	})
+	registerClass("GST", func(line []byte) (interface{}, error) {
+		gst := NewGST()
+		err := json.Unmarshal(line, gst)
+		return gst, err
+	})
+	registerClass("RAW", func(line []byte) (interface{}, error) {
+		raw := NewRAW()
+		err := json.Unmarshal(line, raw)
+		return raw, err
+	})
+	registerClass("RTCM3", func(line []byte) (interface{}, error) {
+		rtcm3 := NewRTCM3()
+		err := json.Unmarshal(line, rtcm3)
+		return rtcm3, err
+	})
+	registerClass("AIS", func(line []byte) (interface{}, error) {
+		ais := NewAIS()
+		err := json.Unmarshal(line, ais)
+		return ais, err
+	})
+	registerClass("POLL", func(line []byte) (interface{}, error) {
+		poll := NewPOLL()
+		err := json.Unmarshal(line, poll)
+		return poll, err
+	})
+	registerClass("SUBFRAME", func(line []byte) (interface{}, error) {
+		subframe := NewSUBFRAME()
+		err := json.Unmarshal(line, subframe)
+		return subframe, err
+	})
+	registerClass("DEVICES", func(line []byte) (interface{}, error) {
+		devices := new(DEVICES)
+		err := json.Unmarshal(line, devices)
+		return devices, err
+	})
+	registerClass("ERROR", func(line []byte) (interface{}, error) {
+		errormsg := new(ERROR)
+		err := json.Unmarshal(line, errormsg)
+		return errormsg, err
+	})
+	registerClass("PPS", func(line []byte) (interface{}, error) {
+		pps := new(PPS)
+		err := json.Unmarshal(line, pps)
+		return pps, err
+	})
+	registerClass("SKY", func(line []byte) (interface{}, error) {
+		sky := NewSKY()
+		err := json.Unmarshal(line, sky)
+		return sky, err
+	})
+	registerClass("TOFF", func(line []byte) (interface{}, error) {
+		toff := NewTOFF()
+		err := json.Unmarshal(line, toff)
+		return toff, err
+	})
+	registerClass("TPV", func(line []byte) (interface{}, error) {
+		tpv := NewTPV()
+		err := json.Unmarshal(line, tpv)
+		return tpv, err
+	})
+	registerClass("VERSION", func(line []byte) (interface{}, error) {
+		version := new(VERSION)
+		err := json.Unmarshal(line, version)
+		return version, err
+	})
+	registerClass("WATCH", func(line []byte) (interface{}, error) {
+		watch := new(WATCH)
+		err := json.Unmarshal(line, watch)
+		return watch, err
+	})
+}
@@ -1,10 +1,11 @@
 /*
 Package gpsd enables for gpsa God clients to connect to gpsd daemons.
 
-This package is standalone, using only core Golang packages.
-It does not require any other components of gpsd to be installed,
-does not require an FFI, or libgps, does not require non-core
-Golang modules.
+This package does not require any other components of gpsd to be
+installed, does not require an FFI, or libgps.  It is otherwise
+standalone, built on core Golang packages, with one exception: the
+Type == "serial" backend uses github.com/tarm/serial to talk to a
+local serial GPS.
 
 This package connect to gpsd daemons and has them send gpsd JSON
 messages.  The JSON packets are decoded and placed into structs
@@ -23,6 +24,8 @@ SPDX-License-Identifier: BSD-2-clause
 package gpsd
 
 import (
+	"bufio" // for bufio.Scanner
+
 	// Warning: Go json module is not fully compatible with Python JSON.
 	// Warning: Go json module does not fully implement the JSON spec.
 	"encoding/json" // for json.Unmarshall()
@@ -39,7 +42,6 @@ import (
 	"math" // for math.Log()
 	"net"  // for net.Dial(), net.Conn, etc.
 	// "reflect"     // for reflect.TypeOf() For debug
-	"strings" // for strings.Split()
 )
 
 // LogLvl is a wrapper over loa.Logger module logging levels.
@@ -249,6 +251,37 @@ func (sat SATELLITE) HlthUsed() string {
         return "N"                         // Unused
 }
 
+// GNSSid.prefix() return the single-letter constellation code used by
+// SATELLITE.ID(), or "" for a GNSSid with no conventional letter.
+func (gnssid GNSSid) prefix() string {
+	if 0 > gnssid || 7 < gnssid {
+		return ""
+	}
+	return []string{"G", "S", "E", "B", "I", "Q", "R", "N"}[gnssid]
+}
+
+// SATELLITE.ID() - return the satellite's identifier the way Stratux
+// (and most gpsd front-ends) display it: a single constellation letter
+// followed by the PRN/SVID, e.g. "G2", "R65", "S138".  For SBAS, some
+// NMEA sources report svid as PRN-87 rather than the full PRN; that
+// offset is undone here so e.g. svid 51 still renders as "S138".
+func (sat SATELLITE) ID() string {
+	prefix := sat.Gnssid.prefix()
+	if 0 == len(prefix) {
+		return sat.Gnssid.String() // "n/a" or "unk"
+	}
+
+	svid := sat.Svid
+	if 0 == svid {
+		svid = sat.PRN
+	}
+	if 1 == int(sat.Gnssid) && 87 > svid { // SBAS
+		svid += 87
+	}
+
+	return fmt.Sprintf("%s%d", prefix, svid)
+}
+
 /* ByGNSS implements sort.Interface based on the GNSS/Svid fields.
  * You can sort []SATELLITE this way:
  *   sort.Sort(gpsd.ByGNSS(sky.Satellites))
@@ -264,6 +297,17 @@ func (a ByGNSS) Less(i, j int) bool {
 }
 func (a ByGNSS) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 
+/* ByID implements sort.Interface based on SATELLITE.ID() rather than
+ * the raw Gnssid/Svid fields ByGNSS sorts on.
+ * You can sort []SATELLITE this way:
+ *   sort.Sort(gpsd.ByID(sky.Satellites))
+ */
+type ByID []SATELLITE
+
+func (a ByID) Len() int           { return len(a) }
+func (a ByID) Less(i, j int) bool { return a[i].ID() < a[j].ID() }
+func (a ByID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
 // SKY, to hold SKY message.
 type SKY struct {
 	Class      string
@@ -385,30 +429,67 @@ type VERSION struct {
 }
 
 // WATCH, to hold WATCH message.
+//
+// Scaled is a *bool, not a bool: gpsd only unscales AIS when scaled is
+// explicitly sent false, so the zero value (unset) has to be
+// distinguishable from "set false" for Marshal() to be able to ask for
+// that. Use gpsd.Bool() to set it.
 type WATCH struct {
-	Class   string
-	Device  string
-	Enable  bool
-	Json    bool
-	Nmea    bool
-	Pps     bool
-	Raw     int
-	Remote  string
-	Scaled  bool
-	Split24 bool
+	Class   string `json:"class,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Enable  bool   `json:"enable,omitempty"`
+	Json    bool   `json:"json,omitempty"`
+	Nmea    bool   `json:"nmea,omitempty"`
+	Pps     bool   `json:"pps,omitempty"`
+	Raw     int    `json:"raw,omitempty"`
+	Remote  string `json:"remote,omitempty"`
+	Scaled  *bool  `json:"scaled,omitempty"`
+	Split24 bool   `json:"split24,omitempty"`
+}
+
+// Bool() returns a *bool pointing at b, for setting WATCH.Scaled (which
+// must tell "unset" apart from "explicitly false").
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Marshal() renders w as the compact JSON object gpsd expects for the
+// "?WATCH=" command, honoring every field instead of just enable/json/
+// pps/device, and omitting whatever is left at its zero value so the
+// wire form stays as small as the hand-written version it replaces.
+func (w WATCH) Marshal() ([]byte, error) {
+	w.Class = "WATCH"
+	return json.Marshal(w)
 }
 
 // describe a context/connection to a GPSD source
 type Context struct {
-	Conn     net.Conn
+	Conn     io.ReadWriteCloser
 	Device   string
 	Filename string
 	GLog     *GLogger // GPSD logging
 	Host     string   // hostname or IP
 	Port     string   // source port
-	// tcp, tcp4, tcp6, udp, udp4, udp6, file, unix (socket)
+	// tcp, tcp4, tcp6, udp, udp4, udp6, file, unix (socket), serial
 	Type  string
 	Watch WATCH // requested WATCH
+
+	// serial port settings, used when Type == "serial".  Zero values
+	// fall back to gpsd's own defaults (4800bps, 8N1).
+	Bps      int    // baud rate
+	Parity   string // "N", "E", or "O"
+	Stopbits int    // 1 or 2
+
+	// Replay controls playback pace for Type == "file": false (the
+	// default) paces sends at one line per second, regardless of the
+	// timing recorded in the file; true streams the file as fast as the consumer can read it, for
+	// replay-driven tests.
+	Replay bool
+
+	// MaxMsgLen caps how long a single GPSD message line may be before
+	// Reader() gives up on it.  0 means defaultMaxMsgLen; raise this for
+	// SKY messages with a very large satellite count.
+	MaxMsgLen int
 }
 
 /* Open() opens a connection to a gpsd source.
@@ -428,11 +509,28 @@ func Open(src *Context) error {
 	case "tcp4":
 		fallthrough
 	case "tcp6":
+		fallthrough
+	case "udp":
+		fallthrough
+	case "udp4":
+		fallthrough
+	case "udp6":
 		src.Conn, err = net.Dial(src.Type, src.Host+":"+src.Port)
 		if nil != err {
 			err = errors.New(fmt.Sprintf(
 				"Failed to connect to GPSD: %v", err))
 		}
+	case "unix":
+		src.Conn, err = net.Dial("unix", src.Filename)
+		if nil != err {
+			err = errors.New(fmt.Sprintf(
+				"Failed to connect to GPSD socket '%s': %v",
+				src.Filename, err))
+		}
+	case "serial":
+		src.Conn, err = openSerial(src)
+	case "file":
+		src.Conn, err = openFile(src)
 	default:
 		err = errors.New(fmt.Sprintf(
 			"Unsupported connection type '%s'\n", src.Type))
@@ -440,6 +538,11 @@ func Open(src *Context) error {
 	return err
 }
 
+// defaultMaxMsgLen is the line-length ceiling Reader() uses when
+// Context.MaxMsgLen is left at 0: generous enough for a SKY message
+// with several dozen SATELLITE entries.
+const defaultMaxMsgLen = 64 * 1024
+
 /* Reader() reads messages from gpsd, parses them, and sends them as
  * structures out the channel.
  * Returns only when connection is broken, EOF, etc.
@@ -447,125 +550,53 @@ func Open(src *Context) error {
  */
 func (src *Context) Reader(gpsDataChan chan interface{}) error {
 
-	buf := make([]byte, 4096)
+	maxLen := src.MaxMsgLen
+	if 0 == maxLen {
+		maxLen = defaultMaxMsgLen
+	}
+
+	// bufio.Scanner buffers internally, so a message split across two
+	// Conn.Read() calls is completed by the next read instead of being
+	// handed to json.Unmarshal() half-finished.
+	scanner := bufio.NewScanner(src.Conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxLen)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if 0 == len(line) {
+			// skip empty lines
+			continue
+		}
 
-	// only leaves the loop on read errors
-	for {
-		// FIXME: Does not handle messages split across reads.
-		n, err := src.Conn.Read(buf)
+		// get a partial decode to find out the class
+		gpsdmsg := new(GPSData)
+		err := json.Unmarshal(line, &gpsdmsg)
 		if nil != err {
-			err = errors.New(fmt.Sprintf(
-				"Failed to read from GPSD: %v", err))
-			return err
+			src.GLog.Log(LOG_WARN,
+				"Failed to unmarshal GPS data: %v\n",
+				err)
+			continue
+		}
+
+		decode, known := messageHandlers[gpsdmsg.Class]
+		if !known {
+			fmt.Printf("Unknown class '%s'\n", gpsdmsg.Class)
+			continue
 		}
 
-		// one read can contain many messages, we hope each is complete.
-		lines := strings.Split(string(buf[:n]), "\n")
-
-		for _, line := range lines {
-
-			if 0 == len(line) {
-				// skip empty lines
-				continue
-			}
-
-			// get a partial decode to find out the class
-			gpsdmsg := new(GPSData)
-			err = json.Unmarshal([]byte(line), &gpsdmsg)
-			if nil != err {
-				src.GLog.Log(LOG_WARN,
-					"Failed to unmarshal GPS data: %v\n",
-					err)
-				continue
-			}
-			// gpsDataChan <- *gpsdmsg
-
-			switch gpsdmsg.Class {
-			case "DEVICES":
-				devices := new(DEVICES)
-				err = json.Unmarshal([]byte(line), &devices)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"DEVICES: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG,
-					"DEVICES %+v\n", devices)
-				gpsDataChan <- devices
-			case "ERROR":
-				errormsg := new(ERROR)
-				err = json.Unmarshal([]byte(line), &errormsg)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"ERROR: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "ERROR %+v\n", errormsg)
-				gpsDataChan <- errormsg
-			case "PPS":
-				pps := new(PPS)
-				err = json.Unmarshal([]byte(line), &pps)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"PPS: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "PPS %+v\n", pps)
-				gpsDataChan <- pps
-			case "SKY":
-				sky := NewSKY()
-				err = json.Unmarshal([]byte(line), &sky)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"SKY: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG,
-					"SKY %+v\n", sky)
-				gpsDataChan <- sky
-			case "TOFF":
-				toff := NewTOFF()
-				err = json.Unmarshal([]byte(line), &toff)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"TOFF: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "TOFF %+v\n", toff)
-				gpsDataChan <- toff
-			case "TPV":
-				tpv := NewTPV()
-				err = json.Unmarshal([]byte(line), &tpv)
-				if nil != err {
-					src.GLog.Log(LOG_WARN,
-						"TPV: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "TPV %+v\n", tpv)
-				gpsDataChan <- tpv
-			case "VERSION":
-				version := new(VERSION)
-				err = json.Unmarshal([]byte(line), &version)
-				if nil != err {
-					src.GLog.Log(LOG_WARN, "VERSION: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "VERSION %+v\n", version)
-				gpsDataChan <- version
-			case "WATCH":
-				watch := new(WATCH)
-				err = json.Unmarshal([]byte(line), &watch)
-				if nil != err {
-					src.GLog.Log(LOG_WARN, "WATCH error: %v\n", err)
-					continue
-				}
-				src.GLog.Log(LOG_PROG, "WATCH %+v\n", watch)
-				gpsDataChan <- watch
-			default:
-				fmt.Printf("Unknown class '%s'\n", gpsdmsg.Class)
-			}
+		msg, err := decode(line)
+		if nil != err {
+			src.GLog.Log(LOG_WARN, "%s: %v\n", gpsdmsg.Class, err)
+			continue
 		}
+		src.GLog.Log(LOG_PROG, "%s %+v\n", gpsdmsg.Class, msg)
+		gpsDataChan <- msg
+	}
+
+	if err := scanner.Err(); nil != err {
+		return errors.New(fmt.Sprintf("Failed to read from GPSD: %v", err))
 	}
+	return errors.New("GPSD connection closed")
 }
 
 // Write to a gpsd connection.
@@ -600,18 +631,17 @@ func ConnGPSD(gpsdConn *Context, gpsDataChan chan interface{}) {
 	}
 	defer gpsdConn.Conn.Close()
 
-	watch := fmt.Sprintf("?WATCH={\"enable\":%v,\"json\":%v,\"pps\":%v",
-		gpsdConn.Watch.Enable, gpsdConn.Watch.Json, gpsdConn.Watch.Pps)
-	if 0 < len(gpsdConn.Watch.Device) {
-		// add device:
-		watch += fmt.Sprintf(",\"device\":\"%s\"", gpsdConn.Watch.Device)
+	watch, err := watchCommand(gpsdConn.Watch)
+	if nil != err {
+		gpsdConn.GLog.Log(LOG_ERROR,
+			"Failed to build WATCH command: %v", err)
+		return
 	}
-	watch += "};\r\n"
 
 	gpsdConn.GLog.Log(LOG_SHOUT,
-		"Sending to GPSD: %v", watch)
+		"Sending to GPSD: %v", string(watch))
 
-	err = gpsdConn.Writer([]byte(watch))
+	err = gpsdConn.Writer(watch)
 	if nil != err {
 		gpsdConn.GLog.Log(LOG_ERROR,
 			"Failed to send command to GPSD: %v", err)
@@ -627,3 +657,26 @@ func ConnGPSD(gpsdConn *Context, gpsDataChan chan interface{}) {
 		return
 	}
 }
+
+// watchCommand() builds the "?WATCH={...}" command gpsd expects, from
+// the fields already stashed in w.
+func watchCommand(w WATCH) ([]byte, error) {
+	body, err := w.Marshal()
+	if nil != err {
+		return nil, err
+	}
+	cmd := append([]byte("?WATCH="), body...)
+	cmd = append(cmd, []byte(";\r\n")...)
+	return cmd, nil
+}
+
+// Close() tears down the underlying connection, if any.  Safe to call
+// more than once, or on a Context that was never opened.
+func (src *Context) Close() error {
+	if nil == src.Conn {
+		return nil
+	}
+	err := src.Conn.Close()
+	src.Conn = nil
+	return err
+}
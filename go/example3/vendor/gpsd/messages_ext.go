@@ -0,0 +1,206 @@
+/*
+Additional gpsd JSON message classes: ATT, IMU, GST, RAW, RTCM3, AIS,
+POLL, and SUBFRAME.  See "man gpsd_json" for their field descriptions.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+// ATT, to hold ATT message: attitude from a compass or dual-antenna
+// receiver (heading, pitch/roll/yaw, and the raw magnetometer/
+// accelerometer/gyro axes it was derived from).
+//
+// Field names follow the underscore style of the wire's mag_x/acc_x/
+// gyro_x etc. keys (see baseline PPS.Clock_nsec, TOFF.Real_sec): Go's
+// JSON matching is case-insensitive but does not ignore underscores,
+// so e.g. MagX would never bind to "mag_x".
+type ATT struct {
+	Class    string
+	Device   string
+	Time     string
+	Heading  float64
+	Mag_st   string
+	Pitch    float64
+	Pitch_st string
+	Yaw      float64
+	Yaw_st   string
+	Roll     float64
+	Roll_st  string
+	Dip      float64
+	Mag_len  float64
+	Mag_x    float64
+	Mag_y    float64
+	Mag_z    float64
+	Acc_len  float64
+	Acc_x    float64
+	Acc_y    float64
+	Acc_z    float64
+	Gyro_x   float64
+	Gyro_y   float64
+	Gyro_z   float64
+	Depth    float64
+	Temp     float64
+}
+
+// NewATT() Return a new ATT, with good defaults
+func NewATT() *ATT {
+	return &ATT{
+		Heading: NaN,
+		Pitch:   NaN,
+		Yaw:     NaN,
+		Roll:    NaN,
+		Dip:     NaN,
+		Mag_len: NaN,
+		Mag_x:   NaN,
+		Mag_y:   NaN,
+		Mag_z:   NaN,
+		Acc_len: NaN,
+		Acc_x:   NaN,
+		Acc_y:   NaN,
+		Acc_z:   NaN,
+		Gyro_x:  NaN,
+		Gyro_y:  NaN,
+		Gyro_z:  NaN,
+		Depth:   NaN,
+		Temp:    NaN,
+	}
+}
+
+// IMU, to hold IMU message: raw accelerometer/gyro/temperature
+// readings from an inertial unit.  See the ATT doc comment above for
+// why these fields use underscores.
+type IMU struct {
+	Class   string
+	Device  string
+	Time    string
+	Temp    float64
+	Accel_x float64
+	Accel_y float64
+	Accel_z float64
+	Gyro_x  float64
+	Gyro_y  float64
+	Gyro_z  float64
+}
+
+// NewIMU() Return a new IMU, with good defaults
+func NewIMU() *IMU {
+	return &IMU{
+		Temp:    NaN,
+		Accel_x: NaN,
+		Accel_y: NaN,
+		Accel_z: NaN,
+		Gyro_x:  NaN,
+		Gyro_y:  NaN,
+		Gyro_z:  NaN,
+	}
+}
+
+// GST, to hold GST message: pseudorange error statistics.
+type GST struct {
+	Class  string
+	Device string
+	Time   string
+	Rms    float64
+	Major  float64
+	Minor  float64
+	Orient float64
+	Lat    float64
+	Lon    float64
+	Alt    float64
+}
+
+// NewGST() Return a new GST, with good defaults
+func NewGST() *GST {
+	return &GST{
+		Rms:    NaN,
+		Major:  NaN,
+		Minor:  NaN,
+		Orient: NaN,
+		Lat:    NaN,
+		Lon:    NaN,
+		Alt:    NaN,
+	}
+}
+
+// RAWSAT, for the satellite entries in a RAW message.
+type RAWSAT struct {
+	Gnssid       GNSSid
+	Svid         int
+	Sigid        int
+	Snr          float64
+	Doppler      float64
+	Carrierphase float64
+	Pseudorange  float64
+}
+
+// RAW, to hold RAW message: pseudorange observations for RTK.
+type RAW struct {
+	Class   string
+	Device  string
+	Time    string
+	RawData []RAWSAT
+}
+
+// NewRAW() Return a new RAW, with good defaults
+func NewRAW() *RAW {
+	return &RAW{}
+}
+
+// RTCM3, to hold RTCM3 message: a differential-correction frame
+// relayed, not decoded, by gpsd.
+type RTCM3 struct {
+	Class  string
+	Device string
+	Type   int
+	Length int
+}
+
+// NewRTCM3() Return a new RTCM3, with good defaults
+func NewRTCM3() *RTCM3 {
+	return &RTCM3{}
+}
+
+// AIS, to hold AIS message: a decoded vessel report.
+type AIS struct {
+	Class  string
+	Device string
+	Type   int
+	Mmsi   int
+	Scaled bool
+}
+
+// NewAIS() Return a new AIS, with good defaults
+func NewAIS() *AIS {
+	return &AIS{}
+}
+
+// POLL, to hold POLL message: a batched snapshot of current state.
+type POLL struct {
+	Class  string
+	Time   string
+	Active int
+	Tpv    []TPV
+	Sky    []SKY
+}
+
+// NewPOLL() Return a new POLL, with good defaults
+func NewPOLL() *POLL {
+	return &POLL{}
+}
+
+// SUBFRAME, to hold SUBFRAME message: raw GPS navigation data.
+type SUBFRAME struct {
+	Class  string
+	Device string
+	Gnssid GNSSid
+	Svid   int
+	Tow17  int
+	Frame  int
+}
+
+// NewSUBFRAME() Return a new SUBFRAME, with good defaults
+func NewSUBFRAME() *SUBFRAME {
+	return &SUBFRAME{}
+}
@@ -0,0 +1,369 @@
+/*
+NMEAReader parses NMEA 0183 sentences (RMC, GGA, VTG, GSA, GSV, ...)
+straight off a Context that has no gpsd daemon in front of it -- e.g. a
+bare serial GPS -- and emits the same *TPV/*SKY values Reader() emits
+from gpsd JSON, so downstream consumers do not care which upstream
+protocol fed them.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// knotsToMPS converts NMEA speed-over-ground (knots) to gpsd's m/s.
+const knotsToMPS = 0.5144444444444445
+
+// NMEAReader accumulates NMEA sentences for the in-progress epoch and
+// emits a *TPV/*SKY once enough sentences have arrived to fill one in.
+type NMEAReader struct {
+	src *Context
+
+	tpv *TPV // filled in by RMC/GGA/VTG, flushed when the next RMC starts a new epoch
+
+	// GSV sentences arrive in parts; buffer per talker until the last
+	// part of a group has arrived before emitting SKY.
+	gsv      map[string][]SATELLITE
+	gsvTotal map[string]int
+
+	// GSA's used-PRN set and DOP describe the fix as a whole, not one
+	// talker: a combined $GNGSA carries them under talker "GN" while the
+	// sky view still arrives as per-constellation $GPGSV/$GLGSV/$GAGSV,
+	// and even without a combined GSA each per-constellation $--GSA
+	// reports the same fix-wide DOP. So they are accumulated across every
+	// GSA seen in the current epoch and applied to every SKY assembled
+	// from it, rather than being keyed by talker.
+	gsaUsed map[int]bool
+	gsaPdop float64
+	gsaHdop float64
+	gsaVdop float64
+}
+
+// NewNMEAReader() returns an NMEAReader ready to parse src.Conn's byte
+// stream.
+func NewNMEAReader(src *Context) *NMEAReader {
+	n := &NMEAReader{
+		src:      src,
+		tpv:      NewTPV(),
+		gsv:      make(map[string][]SATELLITE),
+		gsvTotal: make(map[string]int),
+	}
+	n.resetGSA()
+	return n
+}
+
+// resetGSA() clears the accumulated GSA state at the start of a new
+// epoch, so a satellite dropped from the used list does not linger.
+func (n *NMEAReader) resetGSA() {
+	n.gsaUsed = make(map[int]bool)
+	n.gsaPdop = NaN
+	n.gsaHdop = NaN
+	n.gsaVdop = NaN
+}
+
+/* Reader() reads NMEA sentences from src.Conn, parses them, and sends
+ * populated *TPV/*SKY structs out gpsDataChan.
+ * Returns only when the connection is broken, EOF, etc.
+ */
+func (n *NMEAReader) Reader(gpsDataChan chan interface{}) error {
+
+	scanner := bufio.NewScanner(n.src.Conn)
+	scanner.Buffer(make([]byte, 0, 4096), defaultMaxMsgLen)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if 0 == len(line) {
+			continue
+		}
+		if err := n.parseSentence(line, gpsDataChan); nil != err {
+			n.src.GLog.Log(LOG_WARN, "NMEA: %v\n", err)
+		}
+	}
+
+	if err := scanner.Err(); nil != err {
+		return errors.New(fmt.Sprintf("Failed to read NMEA: %v", err))
+	}
+	return errors.New("NMEA connection closed")
+}
+
+// parseSentence() validates the checksum on one NMEA line, splits off
+// the talker prefix, and dispatches to the matching per-sentence parser.
+func (n *NMEAReader) parseSentence(line string, gpsDataChan chan interface{}) error {
+
+	if !validChecksum(line) {
+		return errors.New(fmt.Sprintf("bad checksum: %s", line))
+	}
+
+	star := strings.IndexByte(line, '*')
+	body := line[1:star] // strip leading '$' and the trailing "*hh"
+	fields := strings.Split(body, ",")
+	if 5 > len(fields[0]) {
+		return errors.New(fmt.Sprintf("short sentence: %s", line))
+	}
+
+	talker := fields[0][:2]
+	sentence := fields[0][2:]
+	gnssid := talkerGNSSid(talker)
+
+	switch sentence {
+	case "RMC":
+		// RMC marks the start of a new epoch: flush whatever GGA/VTG/
+		// RMC data has built up since the previous RMC before starting
+		// the next one. A GGA or VTG that arrives ahead of its own
+		// epoch's RMC is flushed with the previous TPV.
+		if 0 != len(n.tpv.Class) {
+			gpsDataChan <- n.tpv
+			n.tpv = NewTPV()
+			n.resetGSA()
+		}
+		n.parseRMC(fields)
+	case "GGA":
+		n.parseGGA(fields)
+	case "VTG":
+		n.parseVTG(fields)
+	case "GSA":
+		n.parseGSA(fields)
+	case "GSV":
+		n.parseGSV(fields, talker, gnssid, gpsDataChan)
+	default:
+		// GLL, ZDA, GST, GNS, etc: not needed for TPV/SKY, ignore.
+	}
+	return nil
+}
+
+// validChecksum() XORs the bytes between '$' and '*' and compares the
+// result to the two trailing hex digits.
+func validChecksum(line string) bool {
+	if 0 == len(line) || '$' != line[0] {
+		return false
+	}
+	star := strings.IndexByte(line, '*')
+	if 0 > star || star+3 > len(line) {
+		return false
+	}
+	var sum byte
+	for i := 1; i < star; i++ {
+		sum ^= line[i]
+	}
+	want, err := strconv.ParseUint(line[star+1:star+3], 16, 8)
+	if nil != err {
+		return false
+	}
+	return byte(want) == sum
+}
+
+// talkerGNSSid() maps an NMEA talker ID to the matching GNSSid. "GN"
+// is a mixed/combined solution with no single constellation, so it
+// maps to -1 ("n/a"). SATELLITE.Gnssid is set from the talker of the
+// GSV sentence that lists the satellite; GSA sets no Gnssid.
+func talkerGNSSid(talker string) GNSSid {
+	switch talker {
+	case "GP":
+		return 0 // GPS
+	case "GA":
+		return 2 // Galileo
+	case "GB", "BD":
+		return 3 // BeiDou
+	case "GQ":
+		return 5 // QZSS
+	case "GL":
+		return 6 // GLONASS
+	default:
+		return -1
+	}
+}
+
+// nmeaLatLon() converts an NMEA ddmm.mmmm/dddmm.mmmm field plus its
+// hemisphere letter into signed decimal degrees.
+func nmeaLatLon(val, hemi string, isLon bool) float64 {
+	if 0 == len(val) {
+		return NaN
+	}
+	degLen := 2
+	if isLon {
+		degLen = 3
+	}
+	if len(val) < degLen {
+		return NaN
+	}
+	deg, err := strconv.ParseFloat(val[:degLen], 64)
+	if nil != err {
+		return NaN
+	}
+	min, err := strconv.ParseFloat(val[degLen:], 64)
+	if nil != err {
+		return NaN
+	}
+	result := deg + min/60.0
+	if "S" == hemi || "W" == hemi {
+		result = -result
+	}
+	return result
+}
+
+// nmeaTimestamp() combines an RMC ddmmyy date and hhmmss.ss time of day
+// into the ISO8601 string gpsd itself uses for TPV.Time.
+func nmeaTimestamp(date, tod string) string {
+	if 6 > len(date) || 6 > len(tod) {
+		return ""
+	}
+	return fmt.Sprintf("20%s-%s-%sT%s:%s:%sZ",
+		date[4:6], date[2:4], date[0:2],
+		tod[0:2], tod[2:4], tod[4:6])
+}
+
+// parseRMC() -- $--RMC,time,status,lat,NS,lon,EW,speed,track,date,...
+func (n *NMEAReader) parseRMC(fields []string) {
+	if 10 > len(fields) {
+		return
+	}
+	n.tpv.Class = "TPV"
+	n.tpv.Time = nmeaTimestamp(fields[9], fields[1])
+	if "A" == fields[2] {
+		n.tpv.Mode = 2
+	}
+	n.tpv.Lat = GFloat(nmeaLatLon(fields[3], fields[4], false))
+	n.tpv.Lon = GFloat(nmeaLatLon(fields[5], fields[6], true))
+	if speed, err := strconv.ParseFloat(fields[7], 64); nil == err {
+		n.tpv.Speed = speed * knotsToMPS
+	}
+	if track, err := strconv.ParseFloat(fields[8], 64); nil == err {
+		n.tpv.Track = track
+	}
+}
+
+// parseGGA() -- $--GGA,time,lat,NS,lon,EW,quality,numSV,hdop,alt,M,...
+func (n *NMEAReader) parseGGA(fields []string) {
+	if 10 > len(fields) {
+		return
+	}
+	n.tpv.Class = "TPV"
+	n.tpv.Lat = GFloat(nmeaLatLon(fields[2], fields[3], false))
+	n.tpv.Lon = GFloat(nmeaLatLon(fields[4], fields[5], true))
+	if quality, err := strconv.Atoi(fields[6]); nil == err && 0 < quality && 2 > n.tpv.Mode {
+		n.tpv.Mode = 2
+	}
+	if alt, err := strconv.ParseFloat(fields[9], 64); nil == err {
+		n.tpv.AltMSL = alt
+	}
+}
+
+// parseVTG() -- $--VTG,cogTrue,T,cogMag,M,speedKnots,N,speedKmh,K,...
+func (n *NMEAReader) parseVTG(fields []string) {
+	if 8 > len(fields) {
+		return
+	}
+	n.tpv.Class = "TPV"
+	if track, err := strconv.ParseFloat(fields[1], 64); nil == err {
+		n.tpv.Track = track
+	}
+	if speed, err := strconv.ParseFloat(fields[7], 64); nil == err {
+		n.tpv.Speed = speed / 3.6 // km/h -> m/s
+	}
+}
+
+// parseGSA() -- $--GSA,mode,fixType,sv1,...,sv12,pdop,hdop,vdop
+// Merges the used-PRN list into the epoch's accumulated set and updates
+// the fix-wide DOP. A multi-constellation fix emits one GSA per
+// constellation (or one combined $GNGSA), each listing only its own
+// used SVs but carrying the same overall DOP, so PRNs accumulate while
+// DOP is simply overwritten by the latest sentence seen.
+func (n *NMEAReader) parseGSA(fields []string) {
+	if 18 > len(fields) {
+		return
+	}
+	for _, f := range fields[3:15] {
+		if prn, err := strconv.Atoi(f); nil == err {
+			n.gsaUsed[prn] = true
+		}
+	}
+	if pdop, err := strconv.ParseFloat(fields[15], 64); nil == err {
+		n.gsaPdop = pdop
+	}
+	if hdop, err := strconv.ParseFloat(fields[16], 64); nil == err {
+		n.gsaHdop = hdop
+	}
+	if vdop, err := strconv.ParseFloat(fields[17], 64); nil == err {
+		n.gsaVdop = vdop
+	}
+}
+
+// parseGSV() -- $--GSV,numMsgs,msgNum,numSV,[svid,elev,azim,snr]*,...
+// Buffers satellites per talker until msgNum reaches numMsgs, then
+// assembles and emits one SKY for that talker's constellation.
+func (n *NMEAReader) parseGSV(fields []string, talker string, gnssid GNSSid, gpsDataChan chan interface{}) {
+	if 4 > len(fields) {
+		return
+	}
+	total, err := strconv.Atoi(fields[1])
+	if nil != err {
+		return
+	}
+	msgNum, err := strconv.Atoi(fields[2])
+	if nil != err {
+		return
+	}
+	if 1 == msgNum {
+		n.gsv[talker] = nil
+	}
+	n.gsvTotal[talker] = total
+
+	for i := 4; i+4 <= len(fields); i += 4 {
+		svid, err := strconv.Atoi(fields[i])
+		if nil != err || 0 == svid {
+			continue
+		}
+		sat := NewSATELLITE()
+		sat.Gnssid = gnssid
+		sat.Svid = svid
+		sat.PRN = svid
+		if el, err := strconv.ParseFloat(fields[i+1], 64); nil == err {
+			sat.El = GFloat(el)
+		}
+		if az, err := strconv.ParseFloat(fields[i+2], 64); nil == err {
+			sat.Az = GFloat(az)
+		}
+		if ss, err := strconv.ParseFloat(fields[i+3], 64); nil == err {
+			sat.Ss = GFloat(ss)
+		}
+		n.gsv[talker] = append(n.gsv[talker], *sat)
+	}
+
+	if msgNum < total {
+		return // wait for the rest of the group
+	}
+
+	sky := NewSKY()
+	sky.Class = "SKY"
+	sky.Satellites = n.gsv[talker]
+
+	for i := range sky.Satellites {
+		if n.gsaUsed[sky.Satellites[i].Svid] {
+			sky.Satellites[i].Used = true
+		}
+	}
+	sky.Pdop = n.gsaPdop
+	sky.Hdop = n.gsaHdop
+	sky.Vdop = n.gsaVdop
+
+	sky.NSat = GUint(len(sky.Satellites))
+	used := 0
+	for _, sat := range sky.Satellites {
+		if sat.Used {
+			used++
+		}
+	}
+	sky.USat = GUint(used)
+	gpsDataChan <- sky
+
+	delete(n.gsv, talker)
+	delete(n.gsvTotal, talker)
+}
@@ -0,0 +1,165 @@
+/*
+Supervise() wraps Open()+WATCH+Reader() in a reconnect-with-backoff
+loop, so consumers do not each have to write their own.
+
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures the exponential-backoff reconnect loop Supervise()
+// uses between failed connection attempts.
+type Backoff struct {
+	Min    time.Duration // delay before the first retry
+	Max    time.Duration // delay never grows past this
+	Factor float64       // multiplier applied to the delay after each failure
+}
+
+// DefaultBackoff is a reasonable starting point: 1s, doubling up to 30s.
+var DefaultBackoff = Backoff{
+	Min:    time.Second,
+	Max:    30 * time.Second,
+	Factor: 2,
+}
+
+// ConnState reports the supervisor's view of the connection to gpsd,
+// so a UI can tell "gpsd is down" apart from "no fix yet".
+type ConnState struct {
+	Connected     bool
+	LastError     error
+	Attempts      int
+	LastConnectAt time.Time
+	LastMsgAt     time.Time
+}
+
+/* Supervise() runs Open()+WATCH+Reader() in a loop, reconnecting with
+ * exponential backoff (plus jitter) whenever the connection drops, and
+ * re-issuing WATCH on every successful (re)connect.  Repeated failures
+ * are coalesced into a single LOG_WARN instead of a tight spin.
+ *
+ * Publishes a ConnState on stateChan after every connect, message, and
+ * disconnect, so a UI can track liveness; sends are non-blocking, so a
+ * slow or absent listener cannot stall the supervisor.
+ *
+ * Only returns when ctx is cancelled.
+ */
+func Supervise(ctx context.Context, gpsdConn *Context, gpsDataChan chan interface{}, stateChan chan ConnState, backoff Backoff) {
+
+	delay := backoff.Min
+	attempts := 0
+	warned := false
+
+	publish := func(state ConnState) {
+		select {
+		case stateChan <- state:
+		default:
+		}
+	}
+
+	for {
+		if ctxDone(ctx) {
+			return
+		}
+
+		attempts++
+		err := Open(gpsdConn)
+		if nil == err {
+			var watch []byte
+			watch, err = watchCommand(gpsdConn.Watch)
+			if nil == err {
+				err = gpsdConn.Writer(watch)
+			}
+		}
+		if nil != err {
+			if !warned {
+				gpsdConn.GLog.Log(LOG_WARN,
+					"Supervise: gpsd unreachable, retrying: %v", err)
+				warned = true
+			}
+			publish(ConnState{LastError: err, Attempts: attempts})
+			if !sleepOrDone(ctx, jitter(delay)) {
+				return
+			}
+			delay = nextDelay(delay, backoff)
+			continue
+		}
+
+		delay = backoff.Min
+		warned = false
+		now := time.Now()
+		publish(ConnState{Connected: true, Attempts: attempts, LastConnectAt: now, LastMsgAt: now})
+
+		relay := make(chan interface{})
+		done := make(chan error, 1)
+		go func() { done <- gpsdConn.Reader(relay) }()
+
+		var readErr error
+	relayLoop:
+		for {
+			select {
+			case msg := <-relay:
+				publish(ConnState{Connected: true, Attempts: attempts, LastConnectAt: now, LastMsgAt: time.Now()})
+				gpsDataChan <- msg
+			case readErr = <-done:
+				break relayLoop
+			case <-ctx.Done():
+				gpsdConn.Close()
+				return
+			}
+		}
+
+		gpsdConn.Close()
+		attempts = 0
+		publish(ConnState{LastError: readErr})
+
+		if !sleepOrDone(ctx, jitter(backoff.Min)) {
+			return
+		}
+	}
+}
+
+// ctxDone() reports whether ctx has already been cancelled.
+func ctxDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepOrDone() sleeps for d, or returns false early if ctx is
+// cancelled first.
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// jitter() randomizes a backoff delay so many supervised clients don't
+// retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if 0 >= d {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// nextDelay() grows d by backoff.Factor, capped at backoff.Max.
+func nextDelay(d time.Duration, backoff Backoff) time.Duration {
+	next := time.Duration(float64(d) * backoff.Factor)
+	if next > backoff.Max {
+		next = backoff.Max
+	}
+	return next
+}
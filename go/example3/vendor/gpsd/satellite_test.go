@@ -0,0 +1,52 @@
+/*
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import "testing"
+
+func TestSatelliteID(t *testing.T) {
+	cases := []struct {
+		name   string
+		gnssid GNSSid
+		svid   int
+		want   string
+	}{
+		{"GPS", 0, 2, "G2"},
+		{"SBAS full PRN", 1, 138, "S138"},
+		{"SBAS NMEA-offset PRN", 1, 51, "S138"},
+		{"Galileo", 2, 12, "E12"},
+		{"BeiDou", 3, 201, "B201"},
+		{"IMES", 4, 5, "I5"},
+		{"QZSS", 5, 193, "Q193"},
+		{"GLONASS", 6, 65, "R65"},
+		{"NavIC", 7, 3, "N3"},
+	}
+	for _, c := range cases {
+		sat := SATELLITE{Gnssid: c.gnssid, Svid: c.svid}
+		if got := sat.ID(); got != c.want {
+			t.Errorf("%s: SATELLITE{Gnssid:%d,Svid:%d}.ID() = %q, want %q",
+				c.name, c.gnssid, c.svid, got, c.want)
+		}
+	}
+}
+
+func TestSatelliteIDFallback(t *testing.T) {
+	cases := []struct {
+		name   string
+		gnssid GNSSid
+		want   string
+	}{
+		{"negative", -1, "n/a"},
+		{"out of range", 8, "unk"},
+	}
+	for _, c := range cases {
+		sat := SATELLITE{Gnssid: c.gnssid, Svid: 1}
+		if got := sat.ID(); got != c.want {
+			t.Errorf("%s: SATELLITE{Gnssid:%d}.ID() = %q, want %q",
+				c.name, c.gnssid, got, c.want)
+		}
+	}
+}
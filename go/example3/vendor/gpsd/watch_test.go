@@ -0,0 +1,67 @@
+/*
+This file is Copyright by The GPSD Project
+SPDX-License-Identifier: BSD-2-clause
+*/
+
+package gpsd
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWatchMarshalRoundTrip(t *testing.T) {
+	want := WATCH{
+		Device:  "/dev/ttyUSB0",
+		Enable:  true,
+		Json:    true,
+		Nmea:    true,
+		Pps:     true,
+		Raw:     2,
+		Remote:  "tcp://host:2947",
+		Scaled:  Bool(false),
+		Split24: true,
+	}
+
+	body, err := want.Marshal()
+	if nil != err {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+
+	got := new(WATCH)
+	if err := json.Unmarshal(body, got); nil != err {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+
+	if got.Device != want.Device ||
+		got.Enable != want.Enable ||
+		got.Json != want.Json ||
+		got.Nmea != want.Nmea ||
+		got.Pps != want.Pps ||
+		got.Raw != want.Raw ||
+		got.Remote != want.Remote ||
+		nil == got.Scaled || *got.Scaled != *want.Scaled ||
+		got.Split24 != want.Split24 {
+		t.Errorf("round trip mismatch: got %+v, want %+v", got, want)
+	}
+}
+
+func TestWatchMarshalOmitsZeroValues(t *testing.T) {
+	body, err := WATCH{}.Marshal()
+	if nil != err {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if want := `{"class":"WATCH"}`; string(body) != want {
+		t.Errorf("Marshal() = %s, want %s", body, want)
+	}
+}
+
+func TestWatchMarshalScaledFalse(t *testing.T) {
+	body, err := WATCH{Enable: true, Scaled: Bool(false)}.Marshal()
+	if nil != err {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	if want := `{"class":"WATCH","enable":true,"scaled":false}`; string(body) != want {
+		t.Errorf("Marshal() = %s, want %s", body, want)
+	}
+}